@@ -0,0 +1,30 @@
+package bots
+
+import "context"
+
+// Backend is a notification target that a Story can be posted to, edited in
+// place, and deleted from. SlackBackend and TelegramBackend are the two
+// concrete implementations; operators may enable either or both.
+type Backend interface {
+	// Send posts a new message for the story and returns an opaque
+	// message reference (a Slack timestamp or a Telegram message ID) to
+	// be persisted so the message can later be edited or deleted.
+	Send(ctx context.Context, s *Story) (msgRef string, err error)
+	// Edit updates the message previously sent for the story.
+	Edit(ctx context.Context, s *Story) error
+	// Delete removes the message previously sent for the story.
+	Delete(ctx context.Context, s *Story) error
+}
+
+// EnabledBackends returns the notification backends configured via
+// environment variables. Slack and Telegram can be enabled independently.
+func EnabledBackends() []Backend {
+	var backends []Backend
+	if SlackToken() != "" {
+		backends = append(backends, SlackBackend{})
+	}
+	if TelegramToken() != "" {
+		backends = append(backends, TelegramBackend{})
+	}
+	return backends
+}
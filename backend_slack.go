@@ -0,0 +1,58 @@
+package bots
+
+import (
+	"context"
+
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+	"google.golang.org/appengine/log"
+)
+
+// SlackBackend posts story notifications to a Slack channel using the
+// nlopes/slack client. The message reference it returns from Send is the
+// Slack message timestamp, persisted on Story.Timestamp.
+type SlackBackend struct{}
+
+// Send posts a new message to the configured Slack channel.
+func (SlackBackend) Send(ctx context.Context, s *Story) (string, error) {
+	attachments := s.ToSendMessageAttachments(ctx)
+	_, timestamp, err := slackClient(ctx).PostMessageContext(ctx, ChannelID(),
+		slack.MsgOptionAttachments(attachments...),
+		slack.MsgOptionEnableLinkUnfurl(),
+	)
+	if err != nil {
+		log.Errorf(ctx, "story %d: %s could not be sent to Slack: %#v", s.ID, s.Title, err)
+		return "", errors.WithStack(err)
+	}
+	return timestamp, nil
+}
+
+// Edit updates the message previously sent for the story. A story with no
+// Slack timestamp was never actually sent to Slack (e.g. a prior Send that
+// partially failed), so there's nothing to edit.
+func (SlackBackend) Edit(ctx context.Context, s *Story) error {
+	if s.Timestamp == "" {
+		return nil
+	}
+	attachments := s.ToSendMessageAttachments(ctx)
+	_, _, _, err := slackClient(ctx).UpdateMessageContext(ctx, ChannelID(), s.Timestamp,
+		slack.MsgOptionAttachments(attachments...),
+	)
+	if err != nil {
+		log.Errorf(ctx, "story %d: %s could not be edited on Slack: %#v", s.ID, s.Title, err)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Delete removes the message previously sent for the story.
+func (SlackBackend) Delete(ctx context.Context, s *Story) error {
+	if s.Timestamp == "" {
+		return nil
+	}
+	if _, _, err := slackClient(ctx).DeleteMessageContext(ctx, ChannelID(), s.Timestamp); err != nil {
+		log.Errorf(ctx, "story %d: %s could not be deleted on Slack: %#v", s.ID, s.Title, err)
+		return errors.WithStack(err)
+	}
+	return nil
+}
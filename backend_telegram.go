@@ -0,0 +1,112 @@
+package bots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"google.golang.org/appengine/log"
+)
+
+// TelegramBackend posts story notifications to a Telegram chat. The message
+// reference it returns from Send is the Telegram message ID, persisted on
+// Story.TelegramMessageID.
+type TelegramBackend struct{}
+
+func telegramAPIURL(method string) string {
+	return TelegramAPIBase + "bot" + TelegramToken() + "/" + method
+}
+
+// Send posts a new message to the configured Telegram chat.
+func (TelegramBackend) Send(ctx context.Context, s *Story) (string, error) {
+	markup, err := json.Marshal(s.GetReplyMarkup())
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	resp, err := myHTTPClient(ctx).PostForm(telegramAPIURL("sendMessage"), url.Values{
+		"chat_id":      {TelegramChatID()},
+		"text":         {s.Title},
+		"reply_markup": {string(markup)},
+	})
+	if err != nil {
+		log.Errorf(ctx, "story %d: %s could not be sent to Telegram: %#v", s.ID, s.Title, err)
+		return "", errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	var response SendMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !response.OK {
+		return "", errors.Errorf("story %d: telegram sendMessage returned not ok", s.ID)
+	}
+	return strconv.FormatInt(response.Result.MessageID, 10), nil
+}
+
+// Edit updates the message previously sent for the story. A story with no
+// Telegram message ID was never actually sent to Telegram (e.g. a prior Send
+// that partially failed), so there's nothing to edit.
+func (TelegramBackend) Edit(ctx context.Context, s *Story) error {
+	if s.TelegramMessageID == 0 {
+		return nil
+	}
+	req := EditMessageTextRequest{
+		ChatID:      TelegramChatID(),
+		MessageID:   s.TelegramMessageID,
+		Text:        s.Title,
+		ReplyMarkup: s.GetReplyMarkup(),
+	}
+	jsonBytes, err := json.Marshal(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := myHTTPClient(ctx).Post(telegramAPIURL("editMessageText"), "application/json", bytes.NewReader(jsonBytes))
+	if err != nil {
+		log.Errorf(ctx, "story %d: %s could not be edited on Telegram: %#v", s.ID, s.Title, err)
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	var response SendMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.WithStack(err)
+	}
+	if !response.OK {
+		return errors.Errorf("story %d: telegram editMessageText returned not ok", s.ID)
+	}
+	return nil
+}
+
+// Delete removes the message previously sent for the story. Messages posted
+// more than 48 hours ago can no longer be deleted through the Telegram API,
+// and a message already removed by hand is not found; both errors are
+// expected and ignored, see DeleteMessageResponse.ShouldIgnoreError.
+func (TelegramBackend) Delete(ctx context.Context, s *Story) error {
+	if s.TelegramMessageID == 0 {
+		return nil
+	}
+	req := DeleteMessageRequest{ChatID: TelegramChatID(), MessageID: s.TelegramMessageID}
+	jsonBytes, err := json.Marshal(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := myHTTPClient(ctx).Post(telegramAPIURL("deleteMessage"), "application/json", bytes.NewReader(jsonBytes))
+	if err != nil {
+		log.Errorf(ctx, "story %d: %s could not be deleted on Telegram: %#v", s.ID, s.Title, err)
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	var response DeleteMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.WithStack(err)
+	}
+	if !response.OK && !response.ShouldIgnoreError() {
+		return errors.Errorf("story %d: telegram deleteMessage failed: %s", s.ID, response.Description)
+	}
+	return nil
+}
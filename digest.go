@@ -0,0 +1,292 @@
+package bots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/pkg/errors"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+// DigestWindow selects which recap a Digest summarizes.
+type DigestWindow string
+
+const (
+	// DailyDigest summarizes the last 24 hours, posted every morning.
+	DailyDigest DigestWindow = "daily"
+	// WeeklyDigest summarizes the last 7 days, posted Monday mornings.
+	WeeklyDigest DigestWindow = "weekly"
+)
+
+// DigestTopN is how many stories a digest lists, ranked by final score.
+const DigestTopN = 10
+
+// Digest summarizes the top stories saved within a time window.
+type Digest struct {
+	Window  DigestWindow
+	Since   time.Time
+	Until   time.Time
+	Stories []Story
+}
+
+// NewDigest builds a Digest for window, querying every Story saved within
+// the window and ranking them by final score.
+func NewDigest(ctx context.Context, window DigestWindow) (Digest, error) {
+	until := time.Now()
+	since := until.Add(-24 * time.Hour)
+	if window == WeeklyDigest {
+		since = until.Add(-7 * 24 * time.Hour)
+	}
+
+	var stories []Story
+	if _, err := datastore.NewQuery("Story").Filter("LastSave >=", since).GetAll(ctx, &stories); err != nil {
+		return Digest{}, errors.WithStack(err)
+	}
+
+	sort.SliceStable(stories, func(i, j int) bool {
+		return stories[i].Score > stories[j].Score
+	})
+	if len(stories) > DigestTopN {
+		stories = stories[:DigestTopN]
+	}
+
+	return Digest{Window: window, Since: since, Until: until, Stories: stories}, nil
+}
+
+// SortByVelocity re-orders the digest's stories by score velocity instead of
+// final score.
+func (d *Digest) SortByVelocity() {
+	sort.SliceStable(d.Stories, func(i, j int) bool {
+		vi, _ := d.Stories[i].Velocity()
+		vj, _ := d.Stories[j].Velocity()
+		return vi > vj
+	})
+}
+
+// TotalComments sums the comment counts of every story in the digest.
+func (d *Digest) TotalComments() int64 {
+	var total int64
+	for _, s := range d.Stories {
+		total += s.Descendants
+	}
+	return total
+}
+
+// Title is the digest's headline, e.g. "Daily digest: 10 stories, 842 comments".
+func (d *Digest) Title() string {
+	label := "Daily digest"
+	if d.Window == WeeklyDigest {
+		label = "Weekly recap"
+	}
+	return fmt.Sprintf("%s: %d stories, %d comments", label, len(d.Stories), d.TotalComments())
+}
+
+// ToSlackAttachments renders one Slack attachment per story, headlined by a
+// summary attachment with the digest's title.
+func (d *Digest) ToSlackAttachments() []slack.Attachment {
+	attachments := []slack.Attachment{
+		{
+			Fallback: d.Title(),
+			Color:    "#ff6633",
+			Title:    d.Title(),
+		},
+	}
+	for _, s := range d.Stories {
+		scorePerHour, _ := s.Velocity()
+		attachments = append(attachments, slack.Attachment{
+			Fallback:  s.Title,
+			Title:     s.Title,
+			TitleLink: s.URL,
+			Fields: []slack.AttachmentField{
+				{Title: "Score", Value: strconv.FormatInt(s.Score, 10), Short: true},
+				{Title: "Comments", Value: fmt.Sprintf("<%s|%d>", NewsURL(s.ID), s.Descendants), Short: true},
+				{Title: "Velocity", Value: fmt.Sprintf("%.1f pts/hr", scorePerHour), Short: true},
+			},
+		})
+	}
+	return attachments
+}
+
+// ToTelegramMarkup renders a "View on HN" pager for the digest: one button
+// per story, DigestTopN per page. With only DigestTopN stories kept, today
+// that's always a single page.
+func (d *Digest) ToTelegramMarkup() InlineKeyboardMarkup {
+	var rows [][]InlineKeyboardButton
+	for _, s := range d.Stories {
+		rows = append(rows, []InlineKeyboardButton{
+			{Text: s.Title, URL: NewsURL(s.ID)},
+		})
+	}
+	return InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// DigestPost records a digest message that has already been posted for a
+// window, so a later /digest run within the same window edits it in place
+// (while its stories are still live) instead of reposting.
+type DigestPost struct {
+	Window            string    `json:"window"`
+	Since             time.Time `json:"since"`
+	Timestamp         string    `json:"ts"`
+	TelegramMessageID int64     `json:"telegram_message_id,omitempty"`
+}
+
+func digestPostKey(ctx context.Context, window DigestWindow, since time.Time) *datastore.Key {
+	name := string(window) + "-" + since.Format("2006-01-02")
+	return datastore.NewKey(ctx, "DigestPost", name, 0, nil)
+}
+
+// PostOrUpdate posts the digest to every enabled backend, or edits the
+// existing post for this window if one was already made within the window.
+func (d *Digest) PostOrUpdate(ctx context.Context) error {
+	key := digestPostKey(ctx, d.Window, d.Since)
+	var post DigestPost
+	exists := true
+	switch err := datastore.Get(ctx, key, &post); err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		exists = false
+		post = DigestPost{Window: string(d.Window), Since: d.Since}
+	default:
+		return errors.WithStack(err)
+	}
+
+	for _, backend := range EnabledBackends() {
+		var err error
+		switch backend.(type) {
+		case SlackBackend:
+			err = d.postOrUpdateSlack(ctx, &post, exists)
+		case TelegramBackend:
+			err = d.postOrUpdateTelegram(ctx, &post, exists)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := datastore.Put(ctx, key, &post); err != nil {
+		return errors.WithStack(err)
+	}
+	log.Infof(ctx, "posted %s digest: %d stories", d.Window, len(d.Stories))
+	return nil
+}
+
+// postOrUpdateSlack posts or edits the digest's multi-attachment Slack
+// message, recording the message timestamp on post for later edits.
+func (d *Digest) postOrUpdateSlack(ctx context.Context, post *DigestPost, exists bool) error {
+	attachments := d.ToSlackAttachments()
+	if exists && post.Timestamp != "" {
+		if _, _, _, err := slackClient(ctx).UpdateMessageContext(ctx, ChannelID(), post.Timestamp,
+			slack.MsgOptionAttachments(attachments...)); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	_, timestamp, err := slackClient(ctx).PostMessageContext(ctx, ChannelID(), slack.MsgOptionAttachments(attachments...))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	post.Timestamp = timestamp
+	return nil
+}
+
+// postOrUpdateTelegram posts or edits the digest's Telegram message, with an
+// inline "View on HN" pager in place of Slack's attachments, recording the
+// message ID on post for later edits.
+func (d *Digest) postOrUpdateTelegram(ctx context.Context, post *DigestPost, exists bool) error {
+	markup := d.ToTelegramMarkup()
+	if exists && post.TelegramMessageID != 0 {
+		req := EditMessageTextRequest{
+			ChatID:      TelegramChatID(),
+			MessageID:   post.TelegramMessageID,
+			Text:        d.Title(),
+			ReplyMarkup: markup,
+		}
+		jsonBytes, err := json.Marshal(req)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		resp, err := myHTTPClient(ctx).Post(telegramAPIURL("editMessageText"), "application/json", bytes.NewReader(jsonBytes))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer resp.Body.Close()
+
+		var response SendMessageResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return errors.WithStack(err)
+		}
+		if !response.OK {
+			return errors.Errorf("telegram editMessageText for %s digest returned not ok", d.Window)
+		}
+		return nil
+	}
+
+	markupJSON, err := json.Marshal(markup)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resp, err := myHTTPClient(ctx).PostForm(telegramAPIURL("sendMessage"), url.Values{
+		"chat_id":      {TelegramChatID()},
+		"text":         {d.Title()},
+		"reply_markup": {string(markupJSON)},
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	var response SendMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.WithStack(err)
+	}
+	if !response.OK {
+		return errors.Errorf("telegram sendMessage for %s digest returned not ok", d.Window)
+	}
+	post.TelegramMessageID = response.Result.MessageID
+	return nil
+}
+
+// DigestCronEnabled lets operators disable the /digest cron without
+// redeploying cron.yaml, by setting DIGEST_CRON=disabled.
+func DigestCronEnabled() bool {
+	return os.Getenv("DIGEST_CRON") != "disabled"
+}
+
+// digestHandler renders and posts the daily/weekly digest. The window is
+// selected by the `window` query parameter (daily|weekly), set by the
+// DIGEST_CRON-gated entries in cron.yaml; it defaults to daily.
+func digestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	if !DigestCronEnabled() {
+		log.Infof(ctx, "digest cron disabled via DIGEST_CRON env var")
+		return
+	}
+
+	window := DailyDigest
+	if r.URL.Query().Get("window") == string(WeeklyDigest) {
+		window = WeeklyDigest
+	}
+
+	digest, err := NewDigest(ctx, window)
+	if err != nil {
+		loge(ctx, err)
+		return
+	}
+	digest.SortByVelocity()
+
+	if err := digest.PostOrUpdate(ctx); err != nil {
+		loge(ctx, err)
+	}
+}
@@ -0,0 +1,65 @@
+package bots
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestTitle(t *testing.T) {
+	tests := []struct {
+		name   string
+		window DigestWindow
+		stats  []Story
+		want   string
+	}{
+		{"daily, no stories", DailyDigest, nil, "Daily digest: 0 stories, 0 comments"},
+		{"weekly, with stories", WeeklyDigest, []Story{{Descendants: 5}, {Descendants: 7}}, "Weekly recap: 2 stories, 12 comments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Digest{Window: tt.window, Stories: tt.stats}
+			if got := d.Title(); got != tt.want {
+				t.Errorf("Title() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestTotalComments(t *testing.T) {
+	d := Digest{Stories: []Story{{Descendants: 3}, {Descendants: 10}, {Descendants: 0}}}
+	if got := d.TotalComments(); got != 13 {
+		t.Errorf("TotalComments() = %d, want 13", got)
+	}
+}
+
+func TestDigestSortByVelocity(t *testing.T) {
+	now := time.Now()
+	slow := Story{ID: 1, Samples: []VelocitySample{
+		{Timestamp: now, Score: 10},
+		{Timestamp: now.Add(time.Hour), Score: 15},
+	}}
+	fast := Story{ID: 2, Samples: []VelocitySample{
+		{Timestamp: now, Score: 10},
+		{Timestamp: now.Add(time.Hour), Score: 60},
+	}}
+	d := Digest{Stories: []Story{slow, fast}}
+
+	d.SortByVelocity()
+
+	if d.Stories[0].ID != fast.ID {
+		t.Fatalf("Stories[0].ID = %d, want the faster-climbing story (%d) first", d.Stories[0].ID, fast.ID)
+	}
+}
+
+func TestDigestToTelegramMarkupOnePerStory(t *testing.T) {
+	d := Digest{Stories: []Story{{ID: 1, Title: "a"}, {ID: 2, Title: "b"}}}
+	markup := d.ToTelegramMarkup()
+	if len(markup.InlineKeyboard) != 2 {
+		t.Fatalf("len(InlineKeyboard) = %d, want one row per story (2)", len(markup.InlineKeyboard))
+	}
+	for i, row := range markup.InlineKeyboard {
+		if len(row) != 1 {
+			t.Errorf("row %d has %d buttons, want 1", i, len(row))
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -42,10 +43,20 @@ func loge(ctx context.Context, err error) {
 	log.Errorf(ctx, "%+v", err)
 }
 
-var editMessageFunc = delay.Func("editMessage", func(ctx context.Context, itemID int64, timestamp string) {
-	log.Infof(ctx, "editing message: id %d, message timestamp %s", itemID, timestamp)
-	story := Story{ID: itemID, Timestamp: timestamp}
-	err := story.EditMessage(ctx)
+var editMessageFunc = delay.Func("editMessage", func(ctx context.Context, itemID int64, timestamp string, telegramMessageID int64) {
+	log.Infof(ctx, "editing message: id %d, message timestamp %s, telegram message id %d", itemID, timestamp, telegramMessageID)
+	story, err := NewFromDatastore(ctx, itemID)
+	if err != nil {
+		loge(ctx, err)
+		return
+	}
+	// timestamp/telegramMessageID are passed through explicitly (delay.Func
+	// only serializes primitives), but otherwise match what's already on the
+	// loaded entity; keep the entity's own Samples ring buffer intact so
+	// RecordSample below accumulates instead of restarting from scratch.
+	story.Timestamp = timestamp
+	story.TelegramMessageID = telegramMessageID
+	err = story.EditMessage(ctx)
 	if err != nil {
 		if errors.Cause(err) != ErrIgnoredItem {
 			loge(ctx, err)
@@ -73,9 +84,9 @@ var sendMessageFunc = delay.Func("sendMessage", func(ctx context.Context, itemID
 	}
 })
 
-var deleteMessageFunc = delay.Func("deleteMessage", func(ctx context.Context, itemID int64) {
-	log.Infof(ctx, "deleting message: id %d, message id %d", itemID)
-	story := Story{ID: itemID}
+var deleteMessageFunc = delay.Func("deleteMessage", func(ctx context.Context, itemID int64, timestamp string, telegramMessageID int64) {
+	log.Infof(ctx, "deleting message: id %d, timestamp %s, telegram message id %d", itemID, timestamp, telegramMessageID)
+	story := Story{ID: itemID, Timestamp: timestamp, TelegramMessageID: telegramMessageID}
 	if err := story.DeleteMessage(ctx); err != nil {
 		loge(ctx, err)
 	}
@@ -85,6 +96,8 @@ func init() {
 	http.HandleFunc("/edit", editHandler)
 	http.HandleFunc("/poll", handler)
 	http.HandleFunc("/cleanup", cleanUpHandler)
+	http.HandleFunc("/slack/events", slackEventsHandler)
+	http.HandleFunc("/digest", digestHandler)
 }
 
 // WebhookURL is a helper function to get the Slack API Webhook URL.
@@ -100,6 +113,20 @@ func ChannelID() string {
 	return os.Getenv("CHANNEL_ID")
 }
 
+// TelegramToken is a helper function to get the Telegram bot token.
+func TelegramToken() string {
+	return os.Getenv("TELEGRAM_TOKEN")
+}
+
+// TelegramChatID is a helper function to get the Telegram chat ID to post to.
+// Falls back to DefaultChatID when unset.
+func TelegramChatID() string {
+	if chatID := os.Getenv("TELEGRAM_CHAT_ID"); chatID != "" {
+		return chatID
+	}
+	return DefaultChatID
+}
+
 // NewsURL is a helper function to get the URL to the story's HackerNews page.
 func NewsURL(id int64) string {
 	return `https://news.ycombinator.com/item?id=` + strconv.FormatInt(id, 10)
@@ -165,12 +192,13 @@ func editHandler(w http.ResponseWriter, r *http.Request) {
 	defer wg.Wait()
 	if err == nil {
 		log.Infof(ctx, "no unknown news")
+		sortByVelocity(keys, savedStories)
 		wg.Add(len(keys))
 		for i, key := range keys {
-			go func(id int64, timestamp string) {
+			go func(id int64, timestamp string, telegramMessageID int64) {
 				defer wg.Done()
-				editMessageFunc.Call(ctx, id, timestamp)
-			}(key.IntID(), savedStories[i].Timestamp)
+				editMessageFunc.Call(ctx, id, timestamp, telegramMessageID)
+			}(key.IntID(), savedStories[i].Timestamp, savedStories[i].TelegramMessageID)
 		}
 		return
 	}
@@ -184,17 +212,27 @@ func editHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// multiErr is indexed by the original datastore.GetMulti order, so it
+	// must be consumed against the pre-sort keys/savedStories; only the
+	// stories actually being dispatched are sorted by velocity afterwards.
+	var dispatchKeys []*datastore.Key
+	var dispatchStories []Story
 	for i, err := range multiErr {
-		switch {
-		case err == nil:
-			wg.Add(1)
-			go func(id int64, timestamp string) {
-				defer wg.Done()
-				editMessageFunc.Call(ctx, id, timestamp)
-			}(keys[i].IntID(), savedStories[i].Timestamp)
-		default:
+		if err != nil {
 			loge(ctx, err)
+			continue
 		}
+		dispatchKeys = append(dispatchKeys, keys[i])
+		dispatchStories = append(dispatchStories, savedStories[i])
+	}
+	sortByVelocity(dispatchKeys, dispatchStories)
+
+	wg.Add(len(dispatchKeys))
+	for i, key := range dispatchKeys {
+		go func(id int64, timestamp string, telegramMessageID int64) {
+			defer wg.Done()
+			editMessageFunc.Call(ctx, id, timestamp, telegramMessageID)
+		}(key.IntID(), dispatchStories[i].Timestamp, dispatchStories[i].TelegramMessageID)
 	}
 }
 
@@ -222,10 +260,10 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		log.Infof(ctx, "no unknown news")
 		wg.Add(len(keys))
 		for i, key := range keys {
-			go func(id int64, timestamp string) {
+			go func(id int64, timestamp string, telegramMessageID int64) {
 				defer wg.Done()
-				editMessageFunc.Call(ctx, id, timestamp)
-			}(key.IntID(), savedStories[i].Timestamp)
+				editMessageFunc.Call(ctx, id, timestamp, telegramMessageID)
+			}(key.IntID(), savedStories[i].Timestamp, savedStories[i].TelegramMessageID)
 		}
 		return
 	}
@@ -243,10 +281,10 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case err == nil:
 			wg.Add(1)
-			go func(id int64, timestamp string) {
+			go func(id int64, timestamp string, telegramMessageID int64) {
 				defer wg.Done()
-				editMessageFunc.Call(ctx, id, timestamp)
-			}(keys[i].IntID(), savedStories[i].Timestamp)
+				editMessageFunc.Call(ctx, id, timestamp, telegramMessageID)
+			}(keys[i].IntID(), savedStories[i].Timestamp, savedStories[i].TelegramMessageID)
 		case err == datastore.ErrNoSuchEntity:
 			wg.Add(1)
 			go func(id int64) {
@@ -259,6 +297,29 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sortByVelocity reorders keys/savedStories by descending score velocity in
+// place, so /edit dispatches notifications for the fastest-climbing stories
+// first instead of in HN's rank order.
+func sortByVelocity(keys []*datastore.Key, savedStories []Story) {
+	type pair struct {
+		key   *datastore.Key
+		story Story
+	}
+	pairs := make([]pair, len(keys))
+	for i, key := range keys {
+		pairs[i] = pair{key, savedStories[i]}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		vi, _ := pairs[i].story.Velocity()
+		vj, _ := pairs[j].story.Velocity()
+		return vi > vj
+	})
+	for i, p := range pairs {
+		keys[i] = p.key
+		savedStories[i] = p.story
+	}
+}
+
 func getTopStories(ctx context.Context, limit int) ([]int64, error) {
 	resp, err := myHTTPClient(ctx).Get(GetTopStoryURL())
 	if err != nil {
@@ -296,9 +357,9 @@ func cleanUpHandler(w http.ResponseWriter, r *http.Request) {
 
 	for _, story := range allStories {
 		wg.Add(1)
-		go func(id int64) {
+		go func(id int64, timestamp string, telegramMessageID int64) {
 			defer wg.Done()
-			deleteMessageFunc.Call(ctx, id)
-		}(story.ID)
+			deleteMessageFunc.Call(ctx, id, timestamp, telegramMessageID)
+		}(story.ID, story.Timestamp, story.TelegramMessageID)
 	}
 }
@@ -0,0 +1,187 @@
+package bots
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/pkg/errors"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// MutedStory marks a story that operators asked the bot to stop tracking via
+// the `hn mute <id>` command. Muted stories are skipped by ShouldIgnore.
+type MutedStory struct {
+	ID int64 `json:"id"`
+}
+
+// GetMutedKey gets a datastore key for the given muted story ID.
+func GetMutedKey(ctx context.Context, i int64) *datastore.Key {
+	root := datastore.NewKey(ctx, "TopStory", "Root", 0, nil)
+	return datastore.NewKey(ctx, "MutedStory", "", i, root)
+}
+
+// IsMuted reports whether a story has been muted by an operator.
+func IsMuted(ctx context.Context, id int64) bool {
+	var muted MutedStory
+	err := datastore.Get(ctx, GetMutedKey(ctx, id), &muted)
+	return err == nil
+}
+
+// muteStory records a story ID so future polls skip it.
+func muteStory(ctx context.Context, id int64) error {
+	_, err := datastore.Put(ctx, GetMutedKey(ctx, id), &MutedStory{ID: id})
+	return errors.WithStack(err)
+}
+
+// slackEventsHandler receives Slack Events API callbacks at /slack/events. It
+// answers the one-time URL verification challenge and dispatches message
+// events to handleSlackCommand / handleMessageDeleted.
+func slackEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		loge(ctx, errors.WithStack(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionVerifyToken(
+		&slackevents.TokenComparator{VerificationToken: SlackVerificationToken()},
+	))
+	if err != nil {
+		loge(ctx, errors.WithStack(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text")
+		w.Write([]byte(verification.Challenge))
+	case slackevents.CallbackEvent:
+		if inner, ok := event.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+			if inner.SubType == "message_deleted" {
+				if inner.PreviousMessage != nil {
+					handleMessageDeleted(ctx, inner.PreviousMessage.TimeStamp)
+				}
+				return
+			}
+			handleSlackCommand(ctx, inner.Text, inner.Channel)
+		}
+	}
+}
+
+// handleSlackCommand parses a subset of `hn ...` slash-style commands typed
+// directly in the channel, so the bot can be driven interactively instead of
+// only posting one-way updates.
+//
+//	hn top              list the current top stories tracked in datastore
+//	hn search <query>   search tracked stories by title substring
+//	hn mute <id>        stop tracking and posting updates for a story
+func handleSlackCommand(ctx context.Context, text, channel string) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 || strings.ToLower(fields[0]) != "hn" {
+		return
+	}
+
+	client := slackClient(ctx)
+	switch strings.ToLower(fields[1]) {
+	case "top":
+		replyTopStories(ctx, client, channel)
+	case "search":
+		if len(fields) < 3 {
+			return
+		}
+		replySearchStories(ctx, client, channel, strings.Join(fields[2:], " "))
+	case "mute":
+		if len(fields) != 3 {
+			return
+		}
+		id, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return
+		}
+		replyMuteStory(ctx, client, channel, id)
+	}
+}
+
+func replyTopStories(ctx context.Context, client *slack.Client, channel string) {
+	var stories []Story
+	if _, err := datastore.NewQuery("Story").Order("-Score").Limit(10).GetAll(ctx, &stories); err != nil {
+		loge(ctx, errors.WithStack(err))
+		return
+	}
+	var attachments []slack.Attachment
+	for _, s := range stories {
+		attachments = append(attachments, s.ToSendMessageAttachments(ctx)...)
+	}
+	if _, _, err := client.PostMessageContext(ctx, channel, slack.MsgOptionAttachments(attachments...)); err != nil {
+		loge(ctx, errors.WithStack(err))
+	}
+}
+
+func replySearchStories(ctx context.Context, client *slack.Client, channel, query string) {
+	var stories []Story
+	if _, err := datastore.NewQuery("Story").GetAll(ctx, &stories); err != nil {
+		loge(ctx, errors.WithStack(err))
+		return
+	}
+	var attachments []slack.Attachment
+	for _, s := range stories {
+		if strings.Contains(strings.ToLower(s.Title), strings.ToLower(query)) {
+			attachments = append(attachments, s.ToSendMessageAttachments(ctx)...)
+		}
+	}
+	if len(attachments) == 0 {
+		client.PostMessageContext(ctx, channel, slack.MsgOptionText("no stories found for \""+query+"\"", false))
+		return
+	}
+	if _, _, err := client.PostMessageContext(ctx, channel, slack.MsgOptionAttachments(attachments...)); err != nil {
+		loge(ctx, errors.WithStack(err))
+	}
+}
+
+func replyMuteStory(ctx context.Context, client *slack.Client, channel string, id int64) {
+	if err := muteStory(ctx, id); err != nil {
+		loge(ctx, err)
+		return
+	}
+	client.PostMessageContext(ctx, channel, slack.MsgOptionText("muted story "+strconv.FormatInt(id, 10), false))
+}
+
+// handleMessageDeleted garbage-collects the datastore entry for a story
+// whenever its Slack message is deleted out-of-band, so a manually removed
+// post doesn't keep getting edited by the /edit cron forever.
+func handleMessageDeleted(ctx context.Context, timestamp string) {
+	var stories []Story
+	keys, err := datastore.NewQuery("Story").Filter("Timestamp =", timestamp).GetAll(ctx, &stories)
+	if err != nil {
+		loge(ctx, errors.WithStack(err))
+		return
+	}
+	for _, key := range keys {
+		if err := datastore.Delete(ctx, key); err != nil {
+			loge(ctx, errors.WithStack(err))
+		}
+	}
+}
+
+// SlackVerificationToken is a helper function to get the Slack Events API
+// verification token.
+func SlackVerificationToken() string {
+	return os.Getenv("SLACK_VERIFICATION_TOKEN")
+}
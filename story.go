@@ -4,33 +4,41 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/dyatlov/go-opengraph/opengraph"
-	"io/ioutil"
-	"net/url"
-	"strings"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/dinever/hackernews-slack-bot/unfurl"
+	"github.com/nlopes/slack"
 	"github.com/pkg/errors"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/urlfetch"
+	"google.golang.org/appengine/memcache"
 )
 
 // Hot is the sign for a hot story, either because it has high score or it has
 // large number of discussions.
 const Hot = "🔥"
 
+// slackClient builds a Slack API client bound to the request's urlfetch
+// context, so outgoing HTTP calls go through App Engine's urlfetch service.
+func slackClient(ctx context.Context) *slack.Client {
+	return slack.New(SlackToken(), slack.OptionHTTPClient(myHTTPClient(ctx)))
+}
+
 // Story is a struct represents an item stored in datastore.
 // Part of the fields will be saved to datastore.
 type Story struct {
-	ID                  int64     `json:"id"`
-	URL                 string    `json:"url"`
-	Title               string    `json:"title"`
-	Descendants         int64     `json:"descendants"`
-	Score               int64     `json:"score"`
-	Timestamp           string    `json:"ts"`
-	LastSave            time.Time `json:"-"`
-	Type                string    `json:"type"`
+	ID                  int64            `json:"id"`
+	URL                 string           `json:"url"`
+	Title               string           `json:"title"`
+	Descendants         int64            `json:"descendants"`
+	Score               int64            `json:"score"`
+	Timestamp           string           `json:"ts"`
+	TelegramMessageID   int64            `json:"telegram_message_id,omitempty"`
+	LastSave            time.Time        `json:"-"`
+	Type                string           `json:"type"`
+	Samples             []VelocitySample `json:"-"`
 	missingFieldsLoaded bool
 }
 
@@ -45,16 +53,42 @@ func NewFromDatastore(ctx context.Context, id int64) (Story, error) {
 
 // Load implements the PropertyLoadSaver interface.
 func (s *Story) Load(ps []datastore.Property) error {
-	return datastore.LoadStruct(s, ps)
+	var rest []datastore.Property
+	for _, p := range ps {
+		if p.Name != "Samples" {
+			rest = append(rest, p)
+			continue
+		}
+		raw, ok := p.Value.([]byte)
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, &s.Samples); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return datastore.LoadStruct(s, rest)
 }
 
 // Save implements the PropertyLoadSaver interface.
 func (s *Story) Save() ([]datastore.Property, error) {
+	samples, err := json.Marshal(s.Samples)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	return []datastore.Property{
 		{
 			Name:  "Timestamp",
 			Value: s.Timestamp,
 		},
+		{
+			Name:  "TelegramMessageID",
+			Value: s.TelegramMessageID,
+		},
+		{Name: "Score", Value: s.Score},
+		{Name: "Descendants", Value: s.Descendants},
+		{Name: "Title", Value: s.Title, NoIndex: true},
+		{Name: "URL", Value: s.URL, NoIndex: true},
 		{
 			Name:  "ID",
 			Value: s.ID,
@@ -63,6 +97,11 @@ func (s *Story) Save() ([]datastore.Property, error) {
 			Name:  "LastSave",
 			Value: time.Now(),
 		},
+		{
+			Name:    "Samples",
+			Value:   samples,
+			NoIndex: true,
+		},
 	}, nil
 }
 
@@ -83,15 +122,128 @@ func (s *Story) FillMissingFields(ctx context.Context) error {
 }
 
 // ShouldIgnore is a filter for story.
-func (s *Story) ShouldIgnore() bool {
+func (s *Story) ShouldIgnore(ctx context.Context) bool {
 	return s.Type != "story" ||
 		s.Score < ScoreThreshold ||
 		s.Descendants < NumCommentsThreshold ||
-		s.URL == ""
+		s.URL == "" ||
+		IsMuted(ctx, s.ID)
+}
+
+// MaxVelocitySamples is how many (timestamp, score, descendants) samples are
+// kept per story, i.e. roughly the last MaxVelocitySamples /edit ticks.
+const MaxVelocitySamples = 12
+
+// TrendingPercentile is the percentile of score velocity a story's own
+// velocity must exceed to be flagged Trending. The percentile is taken over
+// every story tracked in datastore with a positive velocity, not only the
+// current top 30.
+const TrendingPercentile = 0.9
+
+// Trending is the sign for a story whose score is climbing unusually fast,
+// as opposed to Hot, which only reacts to an absolute score/comment
+// threshold.
+const Trending = "🚀"
+
+// VelocitySample is one point-in-time measurement of a story's score and
+// comment count, used by Velocity to estimate how fast it's climbing.
+type VelocitySample struct {
+	Timestamp   time.Time `json:"ts"`
+	Score       int64     `json:"score"`
+	Descendants int64     `json:"descendants"`
+}
+
+// RecordSample appends the story's current score/descendants to its
+// velocity ring buffer, dropping the oldest sample once it exceeds
+// MaxVelocitySamples.
+func (s *Story) RecordSample(now time.Time) {
+	s.Samples = append(s.Samples, VelocitySample{Timestamp: now, Score: s.Score, Descendants: s.Descendants})
+	if len(s.Samples) > MaxVelocitySamples {
+		s.Samples = s.Samples[len(s.Samples)-MaxVelocitySamples:]
+	}
+}
+
+// Velocity returns the story's score and comment growth rate, in
+// points/comments per hour, measured between its oldest and newest recorded
+// samples. It returns 0, 0 until at least two samples have been recorded.
+func (s *Story) Velocity() (scorePerHour, commentsPerHour float64) {
+	if len(s.Samples) < 2 {
+		return 0, 0
+	}
+	first := s.Samples[0]
+	last := s.Samples[len(s.Samples)-1]
+	hours := last.Timestamp.Sub(first.Timestamp).Hours()
+	if hours <= 0 {
+		return 0, 0
+	}
+	scorePerHour = float64(last.Score-first.Score) / hours
+	commentsPerHour = float64(last.Descendants-first.Descendants) / hours
+	return scorePerHour, commentsPerHour
+}
+
+// trendingPercentileCacheTTL bounds how often scoreVelocityPercentile's full
+// table scan is re-run, since it's recomputed once per story on every /edit
+// tick otherwise (one scan per goroutine in a BatchSize-wide fan-out).
+const trendingPercentileCacheTTL = 5 * time.Minute
+
+// IsTrending reports whether the story's score velocity exceeds
+// TrendingPercentile of the positive velocities across every story currently
+// tracked in datastore.
+func (s *Story) IsTrending(ctx context.Context) bool {
+	scorePerHour, _ := s.Velocity()
+	if scorePerHour <= 0 {
+		return false
+	}
+	return scorePerHour > cachedScoreVelocityPercentile(ctx, TrendingPercentile)
+}
+
+// cachedScoreVelocityPercentile is scoreVelocityPercentile, memoized in
+// memcache for trendingPercentileCacheTTL so concurrent /edit dispatches
+// within the same window share one table scan instead of each running their
+// own, the same caching approach unfurl uses for repeated URL fetches.
+func cachedScoreVelocityPercentile(ctx context.Context, percentile float64) float64 {
+	key := fmt.Sprintf("velocity-percentile:%.2f", percentile)
+	var cached float64
+	if _, err := memcache.Gob.Get(ctx, key, &cached); err == nil {
+		return cached
+	}
+	value := scoreVelocityPercentile(ctx, percentile)
+	if err := memcache.Gob.Set(ctx, &memcache.Item{Key: key, Object: &value, Expiration: trendingPercentileCacheTTL}); err != nil {
+		log.Errorf(ctx, "could not cache velocity percentile: %#v", err)
+	}
+	return value
+}
+
+// scoreVelocityPercentile returns the percentile-th score velocity (points
+// per hour) across every story currently tracked in datastore.
+func scoreVelocityPercentile(ctx context.Context, percentile float64) float64 {
+	var stories []Story
+	if _, err := datastore.NewQuery("Story").GetAll(ctx, &stories); err != nil {
+		log.Errorf(ctx, "could not load stories for velocity percentile: %#v", err)
+		return 0
+	}
+	var velocities []float64
+	for _, story := range stories {
+		if scorePerHour, _ := story.Velocity(); scorePerHour > 0 {
+			velocities = append(velocities, scorePerHour)
+		}
+	}
+	return percentileOf(velocities, percentile)
+}
+
+// percentileOf returns the percentile-th value (0 <= percentile <= 1) of
+// values, or 0 if values is empty. values need not be pre-sorted.
+func percentileOf(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(percentile*float64(len(sorted)-1))]
 }
 
 // ToSendMessageAttachments converts the Story into a Slack attachment struct
-func (s *Story) ToSendMessageAttachments(ctx context.Context) []SlackMessageAttachments {
+func (s *Story) ToSendMessageAttachments(ctx context.Context) []slack.Attachment {
 	var (
 		scoreSuffix   string
 		commentSuffix string
@@ -102,62 +254,52 @@ func (s *Story) ToSendMessageAttachments(ctx context.Context) []SlackMessageAtta
 	if s.Descendants > 100 {
 		commentSuffix = " " + Hot
 	}
-	client := urlfetch.Client(ctx)
-	resp, err := client.Get(s.URL)
+	result, err := unfurl.Get(ctx, s.URL)
 	if err != nil {
-		log.Errorf(ctx, "story %d: %s could not be fetched: %#v", s.ID, s.Title, err.Error())
+		log.Errorf(ctx, "story %d: %s could not be unfurled: %#v", s.ID, s.Title, err)
 	}
-	defer resp.Body.Close()
-	// reads html as a slice of bytes
-	html, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Errorf(ctx, "story %d: could not read from response: %#v", s.ID, err.Error())
+	fields := []slack.AttachmentField{
+		{
+			Title: "Score",
+			Value: fmt.Sprintf("%d+%s", s.Score, scoreSuffix),
+			Short: true,
+		},
+		{
+			Title: "Comments",
+			Value: fmt.Sprintf("<%s|%d+%s>", NewsURL(s.ID), s.Descendants, commentSuffix),
+			Short: true,
+		},
 	}
-	og := opengraph.NewOpenGraph()
-	err = og.ProcessHTML(strings.NewReader(string(html)))
-	var (
-		imageURL    string
-		pageContent string
-		siteName    string
-		siteIcon    string
-	)
-	if err != nil {
-		log.Errorf(ctx, "story %d: %s could not be unfurled: %#v", s.ID, s.Title, err.Error())
-	} else {
-		if len(og.Images) > 0 {
-			imageURL = og.Images[0].URL
-		}
-		pageContent = og.Description
-		siteName = og.SiteName
+	if result.ReadingTime > 0 {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Reading time",
+			Value: fmt.Sprintf("%d min", int(result.ReadingTime.Round(time.Minute)/time.Minute)),
+			Short: true,
+		})
 	}
-	u, err := url.Parse(s.URL)
-	if err != nil {
-		log.Errorf(ctx, "story %d: could not parse URL %s", s.URL)
+	trending := s.IsTrending(ctx)
+	if trending {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Trending",
+			Value: Trending + " climbing fast",
+			Short: true,
+		})
 	}
-	u.Path = "favicon.ico"
-	siteIcon = u.String()
-	return []SlackMessageAttachments{
+	title := s.Title
+	if trending {
+		title = title + " " + Trending
+	}
+	return []slack.Attachment{
 		{
 			Fallback:   s.Title,
 			Color:      "#ff6633",
-			Title:      s.Title,
+			Title:      title,
 			TitleLink:  s.URL,
-			AuthorName: siteName,
-			AuthorIcon: siteIcon,
-			Fields: []*SlackMessageAttachmentField{
-				{
-					Title: "Score",
-					Value: fmt.Sprintf("%d+%s", s.Score, scoreSuffix),
-					Short: true,
-				},
-				{
-					Title: "Comments",
-					Value: fmt.Sprintf("<%s|%d+%s>", NewsURL(s.ID), s.Descendants, commentSuffix),
-					Short: true,
-				},
-			},
-			ThumbURL: imageURL,
-			Text:     pageContent,
+			AuthorName: result.SiteName,
+			AuthorIcon: result.Favicon,
+			Fields:     fields,
+			ThumbURL:   result.Image,
+			Text:       result.Description,
 		},
 	}
 }
@@ -187,39 +329,24 @@ func (s *Story) GetReplyMarkup() InlineKeyboardMarkup {
 	}
 }
 
-// EditMessage send a request to edit a message.
+// EditMessage send a request to edit a message on every enabled backend.
 func (s *Story) EditMessage(ctx context.Context) error {
 	if !s.missingFieldsLoaded {
 		if err := s.FillMissingFields(ctx); err != nil {
 			return errors.WithStack(err)
 		}
 	}
-	if s.ShouldIgnore() {
+	s.RecordSample(time.Now())
+	if s.ShouldIgnore(ctx) {
 		return errors.WithStack(ErrIgnoredItem)
 	}
 
-	attchments := s.ToSendMessageAttachments(ctx)
-	jsonBytes, err := json.Marshal(attchments)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	resp, err := myHTTPClient(ctx).PostForm("https://slack.com/api/chat.update",
-		url.Values{
-			"token":        {SlackToken()},
-			"channel":      {ChannelID()},
-			"ts":           {s.Timestamp},
-			"attachments":  {string(jsonBytes)},
-			"unfurl_links": {"true"},
-		},
-	)
-	if err != nil {
-		log.Errorf(ctx, "story %d: %s could not be edit: %#v", s.ID, s.Title, err)
-		return errors.WithStack(err)
+	for _, backend := range EnabledBackends() {
+		if err := backend.Edit(ctx, s); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	log.Infof(ctx, "edited story %d: %s: %s", s.ID, s.Title, string(body))
+	log.Infof(ctx, "edited story %d: %s", s.ID, s.Title)
 	return nil
 }
 
@@ -232,51 +359,65 @@ func (s *Story) InDatastore(ctx context.Context) bool {
 	return len(keys) != 0
 }
 
-// SendMessage send a request to send a new message.
+// SendMessage send a request to send a new message to every enabled backend.
 func (s *Story) SendMessage(ctx context.Context) error {
 	if !s.missingFieldsLoaded {
 		if err := s.FillMissingFields(ctx); err != nil {
 			return errors.WithStack(err)
 		}
 	}
+	s.RecordSample(time.Now())
 
-	if s.ShouldIgnore() {
+	if s.ShouldIgnore(ctx) {
 		return ErrIgnoredItem
 	} else if s.InDatastore(ctx) {
 		return errors.WithStack(fmt.Errorf("story already posted: %#v", s))
 	}
-	attchments := s.ToSendMessageAttachments(ctx)
-	jsonBytes, err := json.Marshal(attchments)
-	if err != nil {
-		return errors.WithStack(err)
-	}
 
-	respAttachments, err := myHTTPClient(ctx).PostForm("https://slack.com/api/chat.postMessage",
-		url.Values{
-			"token":        {SlackToken()},
-			"channel":      {ChannelID()},
-			"attachments":  {string(jsonBytes)},
-			"unfurl_links": {"true"},
-		},
-	)
-	if err != nil {
-		log.Errorf(ctx, "story %d: %s could not be sent: %#v", s.ID, s.Title, err)
-		return errors.WithStack(err)
-	}
-	defer respAttachments.Body.Close()
-
-	var response SlackMessageResponse
-	err = json.NewDecoder(respAttachments.Body).Decode(&response)
-	if err != nil {
-		return errors.WithStack(err)
+	for i, backend := range EnabledBackends() {
+		ref, err := backend.Send(ctx, s)
+		if err != nil {
+			// If an earlier backend already succeeded, its ref must not be
+			// dropped, or the next /poll tick would find no datastore
+			// entity and repost a duplicate to it. If nothing succeeded
+			// yet, leave no entity behind so /poll keeps retrying every
+			// backend from scratch.
+			if i > 0 {
+				if _, putErr := datastore.Put(ctx, GetKey(ctx, s.ID), s); putErr != nil {
+					loge(ctx, putErr)
+				}
+			}
+			return err
+		}
+		s.setBackendRef(backend, ref)
 	}
-	s.Timestamp = response.Timestamp
 	log.Infof(ctx, "sent story %d: %s", s.ID, s.Title)
 	return nil
 }
 
-// DeleteMessage delete a message from datastore.
+// setBackendRef stores the message reference a backend returned from Send
+// into the Story field that backend reads back in Edit/Delete.
+func (s *Story) setBackendRef(backend Backend, ref string) {
+	switch backend.(type) {
+	case SlackBackend:
+		s.Timestamp = ref
+	case TelegramBackend:
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return
+		}
+		s.TelegramMessageID = id
+	}
+}
+
+// DeleteMessage removes the story's message from every enabled backend and
+// deletes its datastore entry.
 func (s *Story) DeleteMessage(ctx context.Context) error {
+	for _, backend := range EnabledBackends() {
+		if err := backend.Delete(ctx, s); err != nil {
+			loge(ctx, err)
+		}
+	}
 	key := GetKey(ctx, s.ID)
 	if err := datastore.Delete(ctx, key); err != nil {
 		return errors.WithStack(err)
@@ -0,0 +1,80 @@
+package bots
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoryVelocityNeedsTwoSamples(t *testing.T) {
+	var s Story
+	if score, comments := s.Velocity(); score != 0 || comments != 0 {
+		t.Fatalf("Velocity() with no samples = %v, %v, want 0, 0", score, comments)
+	}
+
+	s.RecordSample(time.Now())
+	if score, comments := s.Velocity(); score != 0 || comments != 0 {
+		t.Fatalf("Velocity() with one sample = %v, %v, want 0, 0", score, comments)
+	}
+}
+
+func TestStoryVelocity(t *testing.T) {
+	now := time.Now()
+	s := Story{Samples: []VelocitySample{
+		{Timestamp: now, Score: 10, Descendants: 2},
+		{Timestamp: now.Add(2 * time.Hour), Score: 30, Descendants: 12},
+	}}
+
+	scorePerHour, commentsPerHour := s.Velocity()
+	if scorePerHour != 10 {
+		t.Errorf("scorePerHour = %v, want 10", scorePerHour)
+	}
+	if commentsPerHour != 5 {
+		t.Errorf("commentsPerHour = %v, want 5", commentsPerHour)
+	}
+}
+
+func TestStoryVelocityZeroElapsed(t *testing.T) {
+	now := time.Now()
+	s := Story{Samples: []VelocitySample{
+		{Timestamp: now, Score: 10},
+		{Timestamp: now, Score: 20},
+	}}
+	if score, comments := s.Velocity(); score != 0 || comments != 0 {
+		t.Fatalf("Velocity() with zero elapsed time = %v, %v, want 0, 0", score, comments)
+	}
+}
+
+func TestStoryRecordSampleDropsOldest(t *testing.T) {
+	var s Story
+	now := time.Now()
+	for i := 0; i < MaxVelocitySamples+5; i++ {
+		s.RecordSample(now.Add(time.Duration(i) * time.Minute))
+	}
+	if len(s.Samples) != MaxVelocitySamples {
+		t.Fatalf("len(Samples) = %d, want %d", len(s.Samples), MaxVelocitySamples)
+	}
+	if want := now.Add(5 * time.Minute); !s.Samples[0].Timestamp.Equal(want) {
+		t.Errorf("oldest surviving sample timestamp = %v, want %v", s.Samples[0].Timestamp, want)
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []float64
+		percentile float64
+		want       float64
+	}{
+		{"empty", nil, 0.9, 0},
+		{"single value", []float64{5}, 0.9, 5},
+		{"unsorted input", []float64{30, 10, 20}, 0, 10},
+		{"90th percentile", []float64{10, 20, 30, 40, 50}, 0.9, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentileOf(tt.values, tt.percentile); got != tt.want {
+				t.Errorf("percentileOf(%v, %v) = %v, want %v", tt.values, tt.percentile, got, tt.want)
+			}
+		})
+	}
+}
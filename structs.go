@@ -8,34 +8,6 @@ import (
 // ErrIgnoredItem is returned when the story should be ignored.
 var ErrIgnoredItem = errors.New("item ignored")
 
-// SlackMessageResponse is a struct that maps to the response returned from slack.com/api/chat.postMessage
-type SlackMessageResponse struct {
-	OK          bool                       `json:"ok"`
-	Channel     string                     `json:"channel"`
-	Timestamp   string                     `json:"ts"`
-	Attachments []*SlackMessageAttachments `json:"attachments"`
-}
-
-// SlackMessageAttachments is a struct that maps to the message attachment
-type SlackMessageAttachments struct {
-	Fallback   string                         `json:"fallback"`
-	AuthorName string                         `json:"author_name"`
-	AuthorIcon string                         `json:"author_icon"`
-	Color      string                         `json:"color"`
-	Title      string                         `json:"title"`
-	TitleLink  string                         `json:"title_link"`
-	Fields     []*SlackMessageAttachmentField `json:"fields"`
-	ThumbURL   string                         `json:"thumb_url"`
-	Text       string                         `json:"text"`
-}
-
-// SlackMessageAttachmentField is a struct that maps to the message attachment field
-type SlackMessageAttachmentField struct {
-	Title string `json:"title"`
-	Value string `json:"value"`
-	Short bool   `json:"short"`
-}
-
 // InlineKeyboardMarkup type.
 type InlineKeyboardMarkup struct {
 	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard,omitempty"`
@@ -0,0 +1,81 @@
+package unfurl
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/memcache"
+)
+
+// cacheEntry is the persisted form of a cached Result, plus the validators
+// needed to revalidate with a conditional GET once CacheTTL has elapsed
+// instead of always doing a full re-fetch.
+type cacheEntry struct {
+	Result       Result
+	ETag         string
+	LastModified string
+	CachedAt     time.Time
+}
+
+func cacheKey(ctx context.Context, pageURL string) *datastore.Key {
+	return datastore.NewKey(ctx, "UnfurlCache", pageURL, 0, nil)
+}
+
+// getCache returns a still-fresh cached Result for pageURL, checking
+// memcache first and falling back to datastore, since memcache entries can
+// be evicted at any time under memory pressure.
+func getCache(ctx context.Context, pageURL string) (Result, bool) {
+	entry, ok := loadEntry(ctx, pageURL)
+	if !ok || !isFresh(entry.CachedAt, CacheTTL) {
+		return Result{}, false
+	}
+	return entry.Result, true
+}
+
+// isFresh reports whether a cache entry recorded at cachedAt is still within
+// ttl of now.
+func isFresh(cachedAt time.Time, ttl time.Duration) bool {
+	return time.Since(cachedAt) <= ttl
+}
+
+// getStaleCache returns the cached Result for pageURL regardless of TTL, for
+// reuse when the origin server answers a conditional GET with 304.
+func getStaleCache(ctx context.Context, pageURL string) (Result, bool) {
+	entry, ok := loadEntry(ctx, pageURL)
+	if !ok {
+		return Result{}, false
+	}
+	return entry.Result, true
+}
+
+// getValidators returns the ETag/Last-Modified recorded for pageURL's last
+// fetch, if any, so a re-fetch can be a cheap conditional GET.
+func getValidators(ctx context.Context, pageURL string) (etag, lastModified string) {
+	entry, ok := loadEntry(ctx, pageURL)
+	if !ok {
+		return "", ""
+	}
+	return entry.ETag, entry.LastModified
+}
+
+func loadEntry(ctx context.Context, pageURL string) (cacheEntry, bool) {
+	var entry cacheEntry
+	if _, err := memcache.Gob.Get(ctx, pageURL, &entry); err == nil {
+		return entry, true
+	}
+	if err := datastore.Get(ctx, cacheKey(ctx, pageURL), &entry); err != nil {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func putCache(ctx context.Context, pageURL string, entry cacheEntry) {
+	entry.CachedAt = time.Now()
+	if err := memcache.Gob.Set(ctx, &memcache.Item{Key: pageURL, Object: &entry, Expiration: CacheTTL}); err != nil {
+		loge(ctx, err)
+	}
+	if _, err := datastore.Put(ctx, cacheKey(ctx, pageURL), &entry); err != nil {
+		loge(ctx, err)
+	}
+}
@@ -0,0 +1,27 @@
+package unfurl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsFresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		cachedAt time.Time
+		ttl      time.Duration
+		want     bool
+	}{
+		{"well within ttl", now.Add(-time.Minute), time.Hour, true},
+		{"just under ttl", now.Add(-59 * time.Minute), time.Hour, true},
+		{"past ttl", now.Add(-2 * time.Hour), time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFresh(tt.cachedAt, tt.ttl); got != tt.want {
+				t.Errorf("isFresh(%v ago, %v) = %v, want %v", now.Sub(tt.cachedAt), tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package unfurl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dyatlov/go-opengraph/opengraph"
+	readability "github.com/go-shiori/go-readability"
+	"github.com/pkg/errors"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/urlfetch"
+)
+
+// wordsPerMinute is the reading speed used to estimate extractedContent.readingTime.
+const wordsPerMinute = 200
+
+// extractedContent is the normalized result of one extractor's pass over a
+// fetched page.
+type extractedContent struct {
+	title       string
+	description string
+	imageURL    string
+	siteName    string
+	readingTime time.Duration
+}
+
+// empty reports whether the extractor came back with nothing usable.
+func (e extractedContent) empty() bool {
+	return e.description == "" && e.imageURL == ""
+}
+
+// contentExtractor pulls extractedContent out of a fetched page.
+type contentExtractor interface {
+	extract(ctx context.Context, pageURL string, html []byte) (extractedContent, error)
+}
+
+// contentExtractors are tried in order, OpenGraph first, until one returns a
+// non-empty result. Most HN stories have OG tags; the readability and oEmbed
+// extractors exist for the long tail of long-form blogs and papers that don't.
+var contentExtractors = []contentExtractor{
+	openGraphExtractor{},
+	readabilityExtractor{},
+	oEmbedExtractor{},
+}
+
+// extractContent runs the extractor chain for pageURL and returns the first
+// non-empty result, or a zero extractedContent if none of them found anything.
+func extractContent(ctx context.Context, pageURL string, html []byte) extractedContent {
+	for _, extractor := range contentExtractors {
+		content, err := extractor.extract(ctx, pageURL, html)
+		if err != nil {
+			log.Errorf(ctx, "extractor %T failed for %s: %#v", extractor, pageURL, err)
+			continue
+		}
+		if !content.empty() {
+			return content
+		}
+	}
+	return extractedContent{}
+}
+
+// openGraphExtractor reads og:* meta tags such as og:description, og:image
+// and og:site_name.
+type openGraphExtractor struct{}
+
+func (openGraphExtractor) extract(ctx context.Context, pageURL string, html []byte) (extractedContent, error) {
+	og := opengraph.NewOpenGraph()
+	if err := og.ProcessHTML(strings.NewReader(string(html))); err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	content := extractedContent{
+		title:       og.Title,
+		description: og.Description,
+		siteName:    og.SiteName,
+	}
+	if len(og.Images) > 0 {
+		content.imageURL = og.Images[0].URL
+	}
+	content.readingTime = estimateReadingTime(content.description)
+	return content, nil
+}
+
+// readabilityExtractor falls back to an article-body extraction for pages
+// without OpenGraph tags, pulling the largest text block, lead image and
+// title the same way a reader-mode browser would.
+type readabilityExtractor struct{}
+
+func (readabilityExtractor) extract(ctx context.Context, pageURL string, html []byte) (extractedContent, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	article, err := readability.FromReader(strings.NewReader(string(html)), u)
+	if err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	return extractedContent{
+		title:       article.Title,
+		description: article.Excerpt,
+		imageURL:    article.Image,
+		siteName:    article.SiteName,
+		readingTime: estimateReadingTime(article.TextContent),
+	}, nil
+}
+
+// oEmbedExtractor probes the oEmbed endpoint of sites that advertise one,
+// e.g. YouTube, Twitter and Vimeo, and uses the provider's own title,
+// thumbnail and provider name in place of a scraped description.
+type oEmbedExtractor struct{}
+
+// oEmbedEndpoints maps a URL host suffix to the provider's oEmbed endpoint.
+var oEmbedEndpoints = map[string]string{
+	"youtube.com": "https://www.youtube.com/oembed",
+	"youtu.be":    "https://www.youtube.com/oembed",
+	"twitter.com": "https://publish.twitter.com/oembed",
+	"vimeo.com":   "https://vimeo.com/api/oembed.json",
+}
+
+type oEmbedResponse struct {
+	Title        string `json:"title"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ProviderName string `json:"provider_name"`
+}
+
+func (oEmbedExtractor) extract(ctx context.Context, pageURL string, html []byte) (extractedContent, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	endpoint := oEmbedHost(u.Host)
+	if endpoint == "" {
+		return extractedContent{}, nil
+	}
+	resp, err := urlfetch.Client(ctx).Get(endpoint + "?format=json&url=" + url.QueryEscape(pageURL))
+	if err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	var oembed oEmbedResponse
+	if err := json.Unmarshal(body, &oembed); err != nil {
+		return extractedContent{}, errors.WithStack(err)
+	}
+	return extractedContent{
+		title:       oembed.Title,
+		description: fmt.Sprintf("via %s", oembed.ProviderName),
+		imageURL:    oembed.ThumbnailURL,
+		siteName:    oembed.ProviderName,
+	}, nil
+}
+
+func oEmbedHost(host string) string {
+	for suffix, endpoint := range oEmbedEndpoints {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// estimateReadingTime gives a rough reading-time estimate for a passage of
+// text, assuming an average adult reading speed of wordsPerMinute.
+func estimateReadingTime(text string) time.Duration {
+	words := len(strings.Fields(text))
+	if words == 0 {
+		return 0
+	}
+	minutes := float64(words) / wordsPerMinute
+	if minutes < 1 {
+		minutes = 1
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
@@ -0,0 +1,42 @@
+package unfurl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExtractContentPrefersOpenGraph(t *testing.T) {
+	html := []byte(`<html><head>
+		<meta property="og:description" content="an OG description">
+		<meta property="og:site_name" content="Example">
+	</head><body><p>some article text that readability could also find</p></body></html>`)
+
+	content := extractContent(context.Background(), "https://example.com/article", html)
+	if content.description != "an OG description" {
+		t.Fatalf("description = %q, want the OpenGraph description", content.description)
+	}
+	if content.siteName != "Example" {
+		t.Fatalf("siteName = %q, want %q", content.siteName, "Example")
+	}
+}
+
+func TestExtractContentFallsBackToReadability(t *testing.T) {
+	html := []byte(`<html><body><article><p>` +
+		`This is a long-form article body with enough words in it that readability's ` +
+		`extraction heuristics should pick it up as the main content block of the page ` +
+		`once there is no OpenGraph metadata present anywhere in the document head.` +
+		`</p></article></body></html>`)
+
+	content := extractContent(context.Background(), "https://example.com/long-form", html)
+	if content.empty() {
+		t.Fatal("extractContent() returned an empty result, want the readability fallback to find the article body")
+	}
+}
+
+func TestExtractContentEmptyWhenNothingFound(t *testing.T) {
+	html := []byte(`<html><head></head><body></body></html>`)
+	content := extractContent(context.Background(), "https://example.com/blank", html)
+	if !content.empty() {
+		t.Fatalf("extractContent() = %+v, want empty result for a page with no usable content", content)
+	}
+}
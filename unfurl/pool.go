@@ -0,0 +1,26 @@
+package unfurl
+
+// workerPool is a bounded pool of goroutines fronted by a job channel, so a
+// burst of unfurl calls (e.g. a full /edit tick unfurling up to BatchSize
+// URLs) never runs more HTTP fetches at once than concurrency allows.
+type workerPool struct {
+	jobs chan func()
+}
+
+func newWorkerPool(concurrency int) *workerPool {
+	p := &workerPool{jobs: make(chan func())}
+	for i := 0; i < concurrency; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *workerPool) run() {
+	for job := range p.jobs {
+		job()
+	}
+}
+
+func (p *workerPool) submit(job func()) {
+	p.jobs <- job
+}
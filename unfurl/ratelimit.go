@@ -0,0 +1,74 @@
+package unfurl
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// hostBurst is the number of requests a single host can absorb back-to-back
+// before waitForHost starts throttling it.
+const hostBurst = 2
+
+// hostRefillPerSecond is how many tokens a host's bucket regains per second
+// once it has been drained, i.e. one request every two seconds steady-state.
+const hostRefillPerSecond = 0.5
+
+// hostLimiter is a simple token-bucket rate limiter for one host.
+type hostLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (l *hostLimiter) take() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * hostRefillPerSecond
+	if l.tokens > hostBurst {
+		l.tokens = hostBurst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*hostLimiter{}
+)
+
+func limiterFor(host string) *hostLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[host]
+	if !ok {
+		l = &hostLimiter{tokens: hostBurst, last: time.Now()}
+		limiters[host] = l
+	}
+	return l
+}
+
+// waitForHost blocks, respecting ctx cancellation, until pageURL's host has
+// a free token, so one slow-responding or rate-limiting domain can't starve
+// the rest of the worker pool.
+func waitForHost(ctx context.Context, pageURL string) error {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+	l := limiterFor(u.Host)
+	for !l.take() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package unfurl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostLimiterTakeDrainsBurst(t *testing.T) {
+	l := &hostLimiter{tokens: hostBurst, last: time.Now()}
+	for i := 0; i < hostBurst; i++ {
+		if !l.take() {
+			t.Fatalf("take() #%d = false, want true while burst tokens remain", i)
+		}
+	}
+	if l.take() {
+		t.Fatal("take() = true after burst exhausted, want false")
+	}
+}
+
+func TestHostLimiterTakeRefills(t *testing.T) {
+	l := &hostLimiter{tokens: 0, last: time.Now().Add(-2 * time.Second)}
+	if !l.take() {
+		t.Fatal("take() = false after enough elapsed time to refill a token, want true")
+	}
+}
+
+func TestHostLimiterTakeCapsAtBurst(t *testing.T) {
+	l := &hostLimiter{tokens: 0, last: time.Now().Add(-1 * time.Hour)}
+	taken := 0
+	for l.take() {
+		taken++
+	}
+	if taken != hostBurst {
+		t.Fatalf("took %d tokens after a long idle period, want capped at hostBurst=%d", taken, hostBurst)
+	}
+}
@@ -0,0 +1,156 @@
+// Package unfurl fetches and caches link-preview metadata for story URLs.
+//
+// Get is the single entry point: lookups run through a bounded worker pool,
+// are cached in Memcache/Datastore with a TTL, and are rate-limited per host
+// so one slow domain can't starve the pool. Concurrent lookups of the same
+// URL are not merged; each one runs its own fetch on a cache miss.
+package unfurl
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/urlfetch"
+)
+
+// DefaultConcurrency is the number of URLs unfurled at once when
+// UNFURL_CONCURRENCY is unset.
+const DefaultConcurrency = 4
+
+// FetchTimeout bounds how long a single unfurl is allowed to spend reading
+// an HTML body, shorter than the caller's own request timeout so one slow
+// page can't exhaust a whole /edit tick.
+const FetchTimeout = 5 * time.Second
+
+// CacheTTL is how long a Result is served from cache before being re-fetched.
+const CacheTTL = 6 * time.Hour
+
+// Result is the normalized link-preview metadata for a URL.
+type Result struct {
+	Image       string
+	Description string
+	SiteName    string
+	Favicon     string
+	ReadingTime time.Duration
+}
+
+var pool = newWorkerPool(concurrency())
+
+func concurrency() int {
+	if v := os.Getenv("UNFURL_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return DefaultConcurrency
+}
+
+// Get returns the cached Result for pageURL, unfurling it through the
+// worker pool on a cache miss.
+func Get(ctx context.Context, pageURL string) (Result, error) {
+	if cached, ok := getCache(ctx, pageURL); ok {
+		return cached, nil
+	}
+
+	type outcome struct {
+		result Result
+		err    error
+	}
+	done := make(chan outcome, 1)
+	pool.submit(func() {
+		result, err := unfurl(ctx, pageURL)
+		done <- outcome{result, err}
+	})
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			return Result{}, o.err
+		}
+		return o.result, nil
+	case <-ctx.Done():
+		return Result{}, errors.WithStack(ctx.Err())
+	}
+}
+
+func unfurl(ctx context.Context, pageURL string) (Result, error) {
+	if err := waitForHost(ctx, pageURL); err != nil {
+		return Result{}, errors.WithStack(err)
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+
+	etag, lastModified := getValidators(ctx, pageURL)
+	resp, err := fetch(fetchCtx, pageURL, etag, lastModified)
+	if err != nil {
+		return Result{}, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		if cached, ok := getStaleCache(ctx, pageURL); ok {
+			putCache(ctx, pageURL, cacheEntry{Result: cached, ETag: etag, LastModified: lastModified})
+			return cached, nil
+		}
+	}
+
+	html, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Result{}, errors.WithStack(err)
+	}
+
+	content := extractContent(ctx, pageURL, html)
+	result := Result{
+		Image:       content.imageURL,
+		Description: content.description,
+		SiteName:    content.siteName,
+		Favicon:     favicon(pageURL),
+		ReadingTime: content.readingTime,
+	}
+	putCache(ctx, pageURL, cacheEntry{
+		Result:       result,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+	return result, nil
+}
+
+func fetch(ctx context.Context, pageURL, etag, lastModified string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := urlfetch.Client(ctx).Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp, nil
+}
+
+func favicon(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	u.Path = "favicon.ico"
+	return u.String()
+}
+
+func loge(ctx context.Context, err error) {
+	log.Errorf(ctx, "%+v", err)
+}